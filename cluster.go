@@ -52,7 +52,9 @@ func Cluster(t testing.TB, fsms []raft.FSM, knobs ...Knob) ([]*raft.Raft, func()
 	cluster := &cluster{
 		t:     t,
 		nodes: make(map[int]*node, n),
+		fsms:  make([]raft.FSM, n),
 	}
+	copy(cluster.fsms, fsms)
 
 	for i := 0; i < n; i++ {
 		cluster.nodes[i] = newDefaultNode(t, i)
@@ -65,8 +67,8 @@ func Cluster(t testing.TB, fsms []raft.FSM, knobs ...Knob) ([]*raft.Raft, func()
 	bootstrapCluster(t, cluster.nodes)
 
 	rafts := make([]*raft.Raft, n)
-	for i := range fsms {
-		raft, err := newRaft(fsms[i], cluster.nodes[i])
+	for i := range cluster.fsms {
+		raft, err := newRaft(cluster.fsms[i], cluster.nodes[i])
 		if err != nil {
 			t.Fatalf("failed to start test raft node %d: %v", i, err)
 		}
@@ -79,6 +81,9 @@ func Cluster(t testing.TB, fsms []raft.FSM, knobs ...Knob) ([]*raft.Raft, func()
 
 	cleanup := func() {
 		Shutdown(t, rafts)
+		for _, f := range cluster.cleanups {
+			f()
+		}
 	}
 
 	return rafts, cleanup
@@ -144,8 +149,16 @@ func Other(rafts []*raft.Raft, indexes ...int) int {
 }
 
 type cluster struct {
-	t     testing.TB
-	nodes map[int]*node // Options for node N.
+	t        testing.TB
+	nodes    map[int]*node // Options for node N.
+	fsms     []raft.FSM    // FSM for node N, possibly wrapped by a Knob.
+	cleanups []func()      // Run when the cluster is shut down.
+}
+
+// addCleanup registers a function to be run when the cluster is shut down,
+// for example to remove a temporary directory created by a Knob.
+func (c *cluster) addCleanup(f func()) {
+	c.cleanups = append(c.cleanups, f)
 }
 
 // Hold dependencies for a single node.
@@ -156,7 +169,8 @@ type node struct {
 	Snapshots     raft.SnapshotStore
 	Configuration *raft.Configuration
 	Transport     raft.Transport
-	Bootstrap     bool // Whether to bootstrap the node, making it join the cluster
+	Bootstrap     bool                // Whether to bootstrap the node, making it join the cluster
+	Suffrage      raft.ServerSuffrage // Whether the node is a voter, non-voter or staging server
 }
 
 // Create default dependencies for a single raft node.
@@ -181,6 +195,7 @@ func newDefaultNode(t testing.TB, i int) *node {
 		Snapshots: raft.NewInmemSnapshotStore(),
 		Transport: transport,
 		Bootstrap: true,
+		Suffrage:  raft.Voter,
 	}
 
 	return options
@@ -213,8 +228,9 @@ func bootstrapCluster(t testing.TB, nodes map[int]*node) {
 			continue
 		}
 		server := raft.Server{
-			ID:      raft.ServerID(strconv.Itoa(i)),
-			Address: node1.Transport.LocalAddr(),
+			ID:       raft.ServerID(strconv.Itoa(i)),
+			Address:  node1.Transport.LocalAddr(),
+			Suffrage: node1.Suffrage,
 		}
 		servers = append(servers, server)
 
@@ -0,0 +1,239 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// FSM returns a Knob that transparently wraps each node's raft.FSM with a
+// recording wrapper, which keeps track of the log entries applied so far
+// and lets WaitIndex, WaitApplied and AssertFSMsEqual synchronize on and
+// compare FSM progress across the cluster.
+func FSM() *FSMKnob {
+	return &FSMKnob{}
+}
+
+// FSMKnob instruments the FSMs of a cluster so that other helpers can wait
+// for and compare what has actually been applied to them.
+type FSMKnob struct {
+	t       testing.TB
+	cluster *cluster
+	fsms    []*fsm
+}
+
+func (k *FSMKnob) pre(cluster *cluster) {
+	k.t = cluster.t
+	k.cluster = cluster
+	k.fsms = make([]*fsm, len(cluster.fsms))
+
+	for i := range cluster.fsms {
+		wrapped := &fsm{FSM: cluster.fsms[i]}
+		wrapped.cond = sync.NewCond(&wrapped.mu)
+		k.fsms[i] = wrapped
+		cluster.fsms[i] = wrapped
+	}
+}
+
+func (k *FSMKnob) post(rafts []*raft.Raft) {
+	fsmRegistry.mu.Lock()
+	for i, r := range rafts {
+		fsmRegistry.m[r] = k.fsms[i]
+	}
+	fsmRegistry.mu.Unlock()
+
+	k.cluster.addCleanup(func() {
+		fsmRegistry.mu.Lock()
+		defer fsmRegistry.mu.Unlock()
+		for _, r := range rafts {
+			delete(fsmRegistry.m, r)
+		}
+	})
+}
+
+// WaitIndex blocks until the node-th FSM has applied the log entry at the
+// given index, or the timeout expires.
+func (k *FSMKnob) WaitIndex(node int, index uint64, timeout time.Duration) {
+	helper, ok := k.t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	if !k.fsms[node].waitIndex(index, timeout) {
+		k.t.Fatalf("node %d did not apply index %d within %s", node, index, timeout)
+	}
+}
+
+// WaitApplied issues a Barrier against the current cluster leader and
+// blocks until every node's FSM -- as wrapped by the FSM knob -- has caught
+// up with the last entry the leader's FSM applied before the barrier, or the
+// timeout expires.
+func WaitApplied(t testing.TB, rafts []*raft.Raft, timeout time.Duration) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	leader := leaderOf(t, rafts)
+
+	future := leader.Barrier(timeout)
+	if err := future.Error(); err != nil {
+		t.Fatalf("barrier failed: %v", err)
+	}
+
+	// The barrier entry itself is never passed to FSM.Apply, so wait for the
+	// index of the last entry that the leader's FSM applied before it.
+	var index uint64
+	for i, r := range rafts {
+		if r == leader {
+			f := fsmOf(t, r, i)
+			f.mu.Lock()
+			index = f.index
+			f.mu.Unlock()
+		}
+	}
+
+	for i, r := range rafts {
+		f := fsmOf(t, r, i)
+		if !f.waitIndex(index, timeout) {
+			t.Fatalf("node %d did not apply barrier index %d within %s", i, index, timeout)
+		}
+	}
+}
+
+// AssertFSMsEqual fails the test unless every node's FSM -- as wrapped by
+// the FSM knob -- has recorded the exact same sequence of applied log
+// entries, mirroring the convergence checks performed against
+// hashicorp/raft's own MockFSM in its test suite.
+func AssertFSMsEqual(t testing.TB, rafts []*raft.Raft) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	if len(rafts) == 0 {
+		return
+	}
+
+	want := fsmOf(t, rafts[0], 0).recorded()
+
+	for i := 1; i < len(rafts); i++ {
+		got := fsmOf(t, rafts[i], i).recorded()
+
+		if len(got) != len(want) {
+			t.Fatalf("node %d has applied %d logs, want %d", i, len(got), len(want))
+		}
+		for j := range want {
+			if !bytes.Equal(got[j], want[j]) {
+				t.Fatalf("node %d log entry %d does not match node 0's", i, j)
+			}
+		}
+	}
+}
+
+// fsmRegistry maps each raft.Raft instance created through Cluster to the
+// recording fsm wrapper that the FSM knob installed for it, so that
+// free-standing helpers like WaitApplied and AssertFSMsEqual can look it up
+// given only the public []*raft.Raft slice.
+var fsmRegistry = struct {
+	mu sync.Mutex
+	m  map[*raft.Raft]*fsm
+}{m: make(map[*raft.Raft]*fsm)}
+
+// leaderOf returns the raft node currently in leader state, failing the
+// test if there is none.
+func leaderOf(t testing.TB, rafts []*raft.Raft) *raft.Raft {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	for _, r := range rafts {
+		if r.State() == raft.Leader {
+			return r
+		}
+	}
+	t.Fatalf("no node is currently the leader")
+	return nil
+}
+
+func fsmOf(t testing.TB, r *raft.Raft, index int) *fsm {
+	fsmRegistry.mu.Lock()
+	defer fsmRegistry.mu.Unlock()
+
+	f, ok := fsmRegistry.m[r]
+	if !ok {
+		t.Fatalf("node %d was not created with the FSM knob", index)
+	}
+	return f
+}
+
+// fsm wraps a user-provided raft.FSM, recording the bytes of every log
+// entry applied to it and the index it has reached so far.
+type fsm struct {
+	raft.FSM
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	index uint64
+	logs  [][]byte
+}
+
+func (f *fsm) Apply(log *raft.Log) interface{} {
+	result := f.FSM.Apply(log)
+
+	f.mu.Lock()
+	f.logs = append(f.logs, log.Data)
+	f.index = log.Index
+	f.cond.Broadcast()
+	f.mu.Unlock()
+
+	return result
+}
+
+func (f *fsm) recorded() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logs
+}
+
+// waitIndex blocks until f has applied index, or timeout elapses, in which
+// case it returns false.
+func (f *fsm) waitIndex(index uint64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	timer := time.AfterFunc(timeout, func() {
+		f.mu.Lock()
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for f.index < index {
+		if time.Now().After(deadline) {
+			return false
+		}
+		f.cond.Wait()
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest_test
+
+import (
+	"testing"
+	"time"
+
+	rafttest "github.com/CanonicalLtd/raft-test"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+// WaitIndex, WaitApplied and AssertFSMsEqual all synchronize on what the
+// FSM knob actually recorded as applied to each node.
+func TestFSMKnob_WaitIndexAndApplied(t *testing.T) {
+	fsms := []raft.FSM{&recordingFSM{}, &recordingFSM{}, &recordingFSM{}}
+
+	notify := rafttest.Notify()
+	fsm := rafttest.FSM()
+
+	rafts, cleanup := rafttest.Cluster(t, fsms, notify, fsm)
+	defer cleanup()
+
+	leader := notify.NextAcquired(time.Second)
+
+	future := rafts[leader].Apply([]byte("hello"), time.Second)
+	assert.NoError(t, future.Error())
+
+	fsm.WaitIndex(leader, rafts[leader].LastIndex(), time.Second)
+
+	rafttest.WaitApplied(t, rafts, time.Second)
+	rafttest.AssertFSMsEqual(t, rafts)
+}
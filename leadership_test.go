@@ -0,0 +1,48 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest_test
+
+import (
+	"testing"
+	"time"
+
+	rafttest "github.com/CanonicalLtd/raft-test"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+// Elect, Transfer and Depose let a test script deterministic leadership
+// changes, even when no leader exists yet.
+func TestNotifyKnob_LeadershipScheduling(t *testing.T) {
+	fsms := []raft.FSM{&recordingFSM{}, &recordingFSM{}, &recordingFSM{}}
+
+	notify := rafttest.Notify()
+	rafts, cleanup := rafttest.Cluster(t, fsms, notify)
+	defer cleanup()
+
+	notify.Elect(0, time.Second)
+	assert.Equal(t, raft.Leader, rafts[0].State())
+
+	// Electing the node that is already leader is a no-op.
+	notify.Elect(0, time.Second)
+	assert.Equal(t, raft.Leader, rafts[0].State())
+
+	notify.Transfer(0, 1, time.Second)
+	assert.Equal(t, raft.Leader, rafts[1].State())
+
+	deposed := notify.Depose(time.Second)
+	assert.Equal(t, 1, deposed)
+	assert.NotEqual(t, raft.Leader, rafts[1].State())
+}
@@ -0,0 +1,301 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// Network returns a new NetworkKnob, which wraps each node's transport with
+// a proxy that can be used to simulate partitions and one-way latency
+// between specific node indexes, and packet loss across the whole cluster.
+// Links are only ever cut in both directions at once: there is no way to
+// fail a single direction of a link.
+func Network() *NetworkKnob {
+	return &NetworkKnob{}
+}
+
+// NetworkKnob can be used to control the simulated network connecting the
+// nodes of a cluster, for example to disconnect or isolate specific nodes,
+// to add latency to specific links, or to drop messages cluster-wide.
+type NetworkKnob struct {
+	t       testing.TB
+	mu      sync.Mutex
+	proxies map[int]*proxyTransport
+	addrs   map[raft.ServerAddress]int
+	links   map[linkKey]*link
+	drop    float64
+}
+
+func (k *NetworkKnob) pre(cluster *cluster) {
+	k.t = cluster.t
+	k.proxies = make(map[int]*proxyTransport, len(cluster.nodes))
+	k.addrs = make(map[raft.ServerAddress]int, len(cluster.nodes))
+	k.links = make(map[linkKey]*link)
+
+	for i, node := range cluster.nodes {
+		k.addrs[node.Transport.LocalAddr()] = i
+	}
+
+	for i, node := range cluster.nodes {
+		proxy := &proxyTransport{
+			index:     i,
+			transport: node.Transport,
+			knob:      k,
+		}
+		k.proxies[i] = proxy
+		node.Transport = proxy
+	}
+}
+
+func (k *NetworkKnob) post(rafts []*raft.Raft) {
+}
+
+// Disconnect cuts the link between node i and node j, in both directions.
+//
+// Any RPC between the two nodes issued after this call will fail until Heal
+// is called; RPCs already handed to the underlying transport still complete.
+func (k *NetworkKnob) Disconnect(i, j int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.link(i, j).connected = false
+	k.link(j, i).connected = false
+}
+
+// Isolate disconnects node i from every other node in the cluster.
+func (k *NetworkKnob) Isolate(i int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for j := range k.proxies {
+		if j == i {
+			continue
+		}
+		k.link(i, j).connected = false
+		k.link(j, i).connected = false
+	}
+}
+
+// Heal restores all links previously cut with Disconnect or Isolate, and
+// resets any latency or drop rate configured with SetLatency and DropRate.
+func (k *NetworkKnob) Heal() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.links = make(map[linkKey]*link)
+	k.drop = 0
+}
+
+// SetLatency delays every message sent from node i to node j by the given
+// duration. The link remains one-way: use two calls to add latency in both
+// directions.
+func (k *NetworkKnob) SetLatency(i, j int, latency time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.link(i, j).latency = latency
+}
+
+// DropRate randomly drops outgoing messages across the whole cluster with
+// the given probability, which must be between 0 and 1, or it fails the
+// test. It can be used to simulate a generally lossy network, as opposed to
+// Disconnect and Isolate which simulate hard partitions.
+func (k *NetworkKnob) DropRate(rate float64) {
+	helper, ok := k.t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	if rate < 0 || rate > 1 {
+		k.t.Fatalf("drop rate %v is not between 0 and 1", rate)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.drop = rate
+}
+
+// link must be called with k.mu held. It returns the link state from i to
+// j, creating it with default values if it doesn't exist yet.
+func (k *NetworkKnob) link(i, j int) *link {
+	key := linkKey{from: i, to: j}
+	l, ok := k.links[key]
+	if !ok {
+		l = &link{connected: true}
+		k.links[key] = l
+	}
+	return l
+}
+
+// check must be called without k.mu held. It blocks for the configured
+// latency between from and to and returns an error if the link is down or
+// the message should be dropped.
+func (k *NetworkKnob) check(from int, target raft.ServerAddress) error {
+	k.mu.Lock()
+	to, ok := k.addrs[target]
+	if !ok {
+		k.mu.Unlock()
+		return nil
+	}
+	l := k.link(from, to)
+	connected := l.connected
+	latency := l.latency
+	drop := k.drop
+	k.mu.Unlock()
+
+	if !connected {
+		return fmt.Errorf("raft-test: node %d is unreachable from node %d", to, from)
+	}
+	if drop > 0 && rand.Float64() < drop {
+		return fmt.Errorf("raft-test: message from node %d to node %d was dropped", from, to)
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	return nil
+}
+
+// linkKey identifies a one-way link between two nodes.
+type linkKey struct {
+	from int
+	to   int
+}
+
+// link holds the simulated state of a one-way link between two nodes.
+type link struct {
+	connected bool
+	latency   time.Duration
+}
+
+// proxyTransport wraps a node's raft.Transport, intercepting every RPC so
+// the owning NetworkKnob gets a chance to delay or fail it.
+type proxyTransport struct {
+	index     int
+	transport raft.Transport
+	knob      *NetworkKnob
+}
+
+func (p *proxyTransport) Consumer() <-chan raft.RPC {
+	return p.transport.Consumer()
+}
+
+func (p *proxyTransport) LocalAddr() raft.ServerAddress {
+	return p.transport.LocalAddr()
+}
+
+func (p *proxyTransport) EncodePeer(id raft.ServerID, addr raft.ServerAddress) []byte {
+	return p.transport.EncodePeer(id, addr)
+}
+
+func (p *proxyTransport) DecodePeer(buffer []byte) raft.ServerAddress {
+	return p.transport.DecodePeer(buffer)
+}
+
+func (p *proxyTransport) SetHeartbeatHandler(cb func(rpc raft.RPC)) {
+	p.transport.SetHeartbeatHandler(cb)
+}
+
+func (p *proxyTransport) AppendEntriesPipeline(id raft.ServerID, target raft.ServerAddress) (raft.AppendPipeline, error) {
+	pipeline, err := p.transport.AppendEntriesPipeline(id, target)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyPipeline{index: p.index, target: target, pipeline: pipeline, knob: p.knob}, nil
+}
+
+func (p *proxyTransport) AppendEntries(id raft.ServerID, target raft.ServerAddress, args *raft.AppendEntriesRequest, resp *raft.AppendEntriesResponse) error {
+	if err := p.knob.check(p.index, target); err != nil {
+		return err
+	}
+	return p.transport.AppendEntries(id, target, args, resp)
+}
+
+func (p *proxyTransport) RequestVote(id raft.ServerID, target raft.ServerAddress, args *raft.RequestVoteRequest, resp *raft.RequestVoteResponse) error {
+	if err := p.knob.check(p.index, target); err != nil {
+		return err
+	}
+	return p.transport.RequestVote(id, target, args, resp)
+}
+
+func (p *proxyTransport) InstallSnapshot(id raft.ServerID, target raft.ServerAddress, args *raft.InstallSnapshotRequest, resp *raft.InstallSnapshotResponse, data io.Reader) error {
+	if err := p.knob.check(p.index, target); err != nil {
+		return err
+	}
+	return p.transport.InstallSnapshot(id, target, args, resp, data)
+}
+
+func (p *proxyTransport) TimeoutNow(id raft.ServerID, target raft.ServerAddress, args *raft.TimeoutNowRequest, resp *raft.TimeoutNowResponse) error {
+	if err := p.knob.check(p.index, target); err != nil {
+		return err
+	}
+	return p.transport.TimeoutNow(id, target, args, resp)
+}
+
+// Connect, Disconnect and DisconnectAll implement raft.WithPeers, which
+// bootstrapCluster relies on to wire up the in-memory transports of all
+// nodes.
+//
+// Connect unwraps its peer argument if it is itself a proxyTransport, since
+// the underlying raft.InmemTransport expects to be connected to another
+// *raft.InmemTransport, not to our proxy.
+func (p *proxyTransport) Connect(peer raft.ServerAddress, t raft.Transport) {
+	if other, ok := t.(*proxyTransport); ok {
+		t = other.transport
+	}
+	p.transport.(raft.WithPeers).Connect(peer, t)
+}
+
+func (p *proxyTransport) Disconnect(peer raft.ServerAddress) {
+	p.transport.(raft.WithPeers).Disconnect(peer)
+}
+
+func (p *proxyTransport) DisconnectAll() {
+	p.transport.(raft.WithPeers).DisconnectAll()
+}
+
+// proxyPipeline wraps a pipelined replication stream to a single target, so
+// that a knob can keep intercepting AppendEntries calls even after raft
+// switches a healthy follower from one-off RPCs to pipeline mode.
+type proxyPipeline struct {
+	index    int
+	target   raft.ServerAddress
+	pipeline raft.AppendPipeline
+	knob     *NetworkKnob
+}
+
+func (p *proxyPipeline) AppendEntries(args *raft.AppendEntriesRequest, resp *raft.AppendEntriesResponse) (raft.AppendFuture, error) {
+	if err := p.knob.check(p.index, p.target); err != nil {
+		return nil, err
+	}
+	return p.pipeline.AppendEntries(args, resp)
+}
+
+func (p *proxyPipeline) Consumer() <-chan raft.AppendFuture {
+	return p.pipeline.Consumer()
+}
+
+func (p *proxyPipeline) Close() error {
+	return p.pipeline.Close()
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest_test
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	rafttest "github.com/CanonicalLtd/raft-test"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+// A node that gets isolated with the Network knob does not receive any
+// further log entries, across several heartbeat/commit intervals, until it
+// is healed again.
+func TestNetworkKnob_Isolate(t *testing.T) {
+	fsms := []*recordingFSM{{}, {}, {}}
+	raftFSMs := []raft.FSM{fsms[0], fsms[1], fsms[2]}
+
+	net := rafttest.Network()
+	notify := rafttest.Notify()
+
+	rafts, cleanup := rafttest.Cluster(t, raftFSMs, net, notify)
+	defer cleanup()
+
+	leader := notify.NextAcquired(time.Second)
+	follower := rafttest.Other(rafts, leader)
+
+	net.Isolate(follower)
+
+	future := rafts[leader].Apply([]byte("hello"), time.Second)
+	assert.NoError(t, future.Error())
+
+	// Give the isolated follower several heartbeat/commit intervals worth
+	// of time to (wrongly) catch up.
+	time.Sleep(200 * time.Millisecond)
+
+	assert.Equal(t, 0, fsms[follower].len())
+
+	net.Heal()
+
+	deadline := time.Now().Add(time.Second)
+	for fsms[follower].len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, fsms[follower].len())
+}
+
+// recordingFSM is a minimal raft.FSM that just records the log entries
+// applied to it, used to assert on what a node has or hasn't replicated.
+type recordingFSM struct {
+	mu   sync.Mutex
+	logs [][]byte
+}
+
+func (f *recordingFSM) Apply(log *raft.Log) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs = append(f.logs, log.Data)
+	return nil
+}
+
+func (f *recordingFSM) Snapshot() (raft.FSMSnapshot, error) {
+	return nil, nil
+}
+
+func (f *recordingFSM) Restore(io.ReadCloser) error {
+	return nil
+}
+
+func (f *recordingFSM) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.logs)
+}
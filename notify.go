@@ -15,7 +15,9 @@
 package rafttest
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -103,6 +105,118 @@ func (k *NotifyKnob) nextMatching(timeout time.Duration, acquired bool) int {
 	}
 }
 
+// Elect makes the given node become the cluster leader, transferring
+// leadership away from the current leader if there is one, and blocks
+// until the target node is actually in leader state.
+//
+// If no node is leader yet -- e.g. right after Cluster() -- there is no
+// LeadershipTransfer to piggy-back on, so Elect lets the natural election
+// play out and, if a different node wins it, immediately issues a Transfer
+// from that node to the requested one. Either way Elect only returns once
+// the requested node is leader, or fails the test if that can't be
+// achieved within the timeout.
+func (k *NotifyKnob) Elect(node int, timeout time.Duration) {
+	helper, ok := k.t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	if k.rafts[node].State() == raft.Leader {
+		return
+	}
+
+	id := raft.ServerID(strconv.Itoa(node))
+
+	for _, r := range k.rafts {
+		if r.State() != raft.Leader || r == k.rafts[node] {
+			continue
+		}
+		addr, err := serverAddress(r, id)
+		if err != nil {
+			k.t.Fatalf("failed to find address of node %d: %v", node, err)
+		}
+		future := r.LeadershipTransferToServer(id, addr)
+		if err := future.Error(); err != nil {
+			k.t.Fatalf("failed to transfer leadership to node %d: %v", node, err)
+		}
+
+		if acquired := k.NextAcquired(timeout); acquired != node {
+			k.t.Fatalf("node %d acquired leadership instead of node %d", acquired, node)
+		}
+		return
+	}
+
+	// No node was leader yet, so there was nothing to transfer away from:
+	// let the natural election run its course, and correct it with a
+	// Transfer if a different node happens to win.
+	if acquired := k.NextAcquired(timeout); acquired != node {
+		k.Transfer(acquired, node, timeout)
+	}
+}
+
+// Depose forces the current cluster leader to step down, transferring
+// leadership to any other node, and blocks until NextLost fires for it. It
+// returns the index of the deposed node.
+func (k *NotifyKnob) Depose(timeout time.Duration) int {
+	helper, ok := k.t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	for _, r := range k.rafts {
+		if r.State() != raft.Leader {
+			continue
+		}
+		future := r.LeadershipTransfer()
+		if err := future.Error(); err != nil {
+			k.t.Fatalf("failed to depose leader: %v", err)
+		}
+		break
+	}
+
+	return k.NextLost(timeout)
+}
+
+// Transfer transfers leadership from node 'from' to node 'to', wrapping
+// raft's own LeadershipTransferToServer with the same wait logic already
+// used by NextAcquired.
+func (k *NotifyKnob) Transfer(from, to int, timeout time.Duration) {
+	helper, ok := k.t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	id := raft.ServerID(strconv.Itoa(to))
+	addr, err := serverAddress(k.rafts[from], id)
+	if err != nil {
+		k.t.Fatalf("failed to find address of node %d: %v", to, err)
+	}
+
+	future := k.rafts[from].LeadershipTransferToServer(id, addr)
+	if err := future.Error(); err != nil {
+		k.t.Fatalf("failed to transfer leadership from node %d to node %d: %v", from, to, err)
+	}
+
+	if acquired := k.nextMatching(timeout, true); acquired != to {
+		k.t.Fatalf("node %d acquired leadership instead of node %d", acquired, to)
+	}
+}
+
+// serverAddress looks up the address of the server with the given ID in
+// r's current configuration.
+func serverAddress(r *raft.Raft, id raft.ServerID) (raft.ServerAddress, error) {
+	future := r.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return "", err
+	}
+	for _, server := range future.Configuration().Servers {
+		if server.ID == id {
+			return server.Address, nil
+		}
+	}
+	return "", fmt.Errorf("no server with ID %s", id)
+}
+
 func (k *NotifyKnob) pre(cluster *cluster) {
 	k.t = cluster.t
 
@@ -0,0 +1,185 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// Servers returns a Knob that overrides the suffrage of specific nodes in
+// the initial cluster configuration, keyed by node index. Nodes not
+// mentioned in suffrages are bootstrapped as regular voters.
+//
+// This can be used together with AddVoter, AddNonvoter, PromoteNonvoter and
+// RemoveServer to exercise read-only replica and rolling membership change
+// scenarios.
+func Servers(suffrages map[int]raft.ServerSuffrage) Knob {
+	return &configKnob{apply: func(cluster *cluster) {
+		for i, suffrage := range suffrages {
+			node, ok := cluster.nodes[i]
+			if !ok {
+				cluster.t.Fatalf("no node with index %d", i)
+			}
+			node.Suffrage = suffrage
+		}
+	}}
+}
+
+// AddVoter adds node as a new voting server to a running cluster, issuing
+// the request against the current leader, and blocks until the
+// configuration change has been committed on every live node.
+func AddVoter(t testing.TB, rafts []*raft.Raft, node int, timeout time.Duration) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	id := raft.ServerID(strconv.Itoa(node))
+	addr := raft.ServerAddress(strconv.Itoa(node))
+
+	future := leaderOf(t, rafts).AddVoter(id, addr, 0, timeout)
+	if err := future.Error(); err != nil {
+		t.Fatalf("failed to add node %d as voter: %v", node, err)
+	}
+
+	waitConfiguration(t, rafts, timeout, func(configuration raft.Configuration) bool {
+		return serverSuffrage(configuration, id) == raft.Voter
+	})
+}
+
+// AddNonvoter adds node as a new non-voting server to a running cluster,
+// issuing the request against the current leader, and blocks until the
+// configuration change has been committed on every live node.
+func AddNonvoter(t testing.TB, rafts []*raft.Raft, node int, timeout time.Duration) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	id := raft.ServerID(strconv.Itoa(node))
+	addr := raft.ServerAddress(strconv.Itoa(node))
+
+	future := leaderOf(t, rafts).AddNonvoter(id, addr, 0, timeout)
+	if err := future.Error(); err != nil {
+		t.Fatalf("failed to add node %d as non-voter: %v", node, err)
+	}
+
+	waitConfiguration(t, rafts, timeout, func(configuration raft.Configuration) bool {
+		return serverSuffrage(configuration, id) == raft.Nonvoter
+	})
+}
+
+// PromoteNonvoter promotes an existing non-voting node to full voter
+// status, by issuing another AddVoter request for its ID against the
+// current leader, and blocks until the configuration change has been
+// committed on every live node.
+func PromoteNonvoter(t testing.TB, rafts []*raft.Raft, node int, timeout time.Duration) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	AddVoter(t, rafts, node, timeout)
+}
+
+// RemoveServer removes node from a running cluster, issuing the request
+// against the current leader, and blocks until the configuration change has
+// been committed on every live node.
+func RemoveServer(t testing.TB, rafts []*raft.Raft, node int, timeout time.Duration) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	id := raft.ServerID(strconv.Itoa(node))
+
+	future := leaderOf(t, rafts).RemoveServer(id, 0, timeout)
+	if err := future.Error(); err != nil {
+		t.Fatalf("failed to remove node %d: %v", node, err)
+	}
+
+	waitConfiguration(t, rafts, timeout, func(configuration raft.Configuration) bool {
+		return serverSuffrage(configuration, id) == -1
+	})
+
+	markRemoved(rafts[node])
+}
+
+// waitConfiguration blocks until match returns true for the configuration
+// of every node still part of rafts, or timeout elapses.
+//
+// Nodes previously evicted with RemoveServer are skipped: once a node is
+// removed from the cluster it stops receiving configuration updates, so its
+// own GetConfiguration snapshot is frozen and would otherwise never match a
+// later membership change.
+func waitConfiguration(t testing.TB, rafts []*raft.Raft, timeout time.Duration, match func(raft.Configuration) bool) {
+	helper, ok := t.(testingHelper)
+	if ok {
+		helper.Helper()
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for i, r := range rafts {
+		if isRemoved(r) {
+			continue
+		}
+		check := func() bool {
+			future := r.GetConfiguration()
+			if err := future.Error(); err != nil {
+				return false
+			}
+			return match(future.Configuration())
+		}
+		remaining := time.Until(deadline)
+		waitTimeout(remaining, t, check, 25*time.Millisecond, "configuration change did not converge on node "+strconv.Itoa(i))
+	}
+}
+
+// removedRafts tracks raft instances evicted from their cluster with
+// RemoveServer, so waitConfiguration can stop waiting on their frozen
+// configuration snapshots.
+var removedRafts = struct {
+	mu sync.Mutex
+	m  map[*raft.Raft]bool
+}{m: make(map[*raft.Raft]bool)}
+
+func markRemoved(r *raft.Raft) {
+	removedRafts.mu.Lock()
+	defer removedRafts.mu.Unlock()
+	removedRafts.m[r] = true
+}
+
+func isRemoved(r *raft.Raft) bool {
+	removedRafts.mu.Lock()
+	defer removedRafts.mu.Unlock()
+	return removedRafts.m[r]
+}
+
+// serverSuffrage returns the suffrage of the server with the given ID in
+// configuration, or -1 if there's no such server.
+func serverSuffrage(configuration raft.Configuration, id raft.ServerID) raft.ServerSuffrage {
+	for _, server := range configuration.Servers {
+		if server.ID == id {
+			return server.Suffrage
+		}
+	}
+	return -1
+}
@@ -0,0 +1,40 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest_test
+
+import (
+	"testing"
+	"time"
+
+	rafttest "github.com/CanonicalLtd/raft-test"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+// A rolling membership change -- removing one server and then adding
+// another -- converges even though the removed server's own configuration
+// snapshot is frozen and never reflects the later change.
+func TestServers_RollingMembershipChange(t *testing.T) {
+	fsms := []raft.FSM{&recordingFSM{}, &recordingFSM{}, &recordingFSM{}}
+
+	notify := rafttest.Notify()
+	rafts, cleanup := rafttest.Cluster(t, fsms, notify)
+	defer cleanup()
+
+	notify.NextAcquired(time.Second)
+
+	rafttest.RemoveServer(t, rafts, 2, time.Second)
+	rafttest.AddVoter(t, rafts, 3, time.Second)
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+// LogStore returns a Knob that overrides the default in-memory log store of
+// each node with the store returned by f for that node's index.
+func LogStore(f func(i int) raft.LogStore) Knob {
+	return &configKnob{apply: func(cluster *cluster) {
+		for i, node := range cluster.nodes {
+			node.Logs = f(i)
+		}
+	}}
+}
+
+// SnapshotStore returns a Knob that overrides the default in-memory
+// snapshot store of each node with the store returned by f for that node's
+// index.
+func SnapshotStore(f func(i int) raft.SnapshotStore) Knob {
+	return &configKnob{apply: func(cluster *cluster) {
+		for i, node := range cluster.nodes {
+			node.Snapshots = f(i)
+		}
+	}}
+}
+
+// FileStores is a convenience Knob that replaces the default in-memory log,
+// stable and snapshot stores of every node with disk-backed ones, rooted in
+// a temporary directory created under dir. The directory tree of each node
+// is removed when the cluster is shut down.
+func FileStores(dir string) Knob {
+	return &configKnob{apply: func(cluster *cluster) {
+		for i, node := range cluster.nodes {
+			path, err := ioutil.TempDir(dir, fmt.Sprintf("raft-test-%d-", i))
+			if err != nil {
+				cluster.t.Fatalf("failed to create store directory for node %d: %v", i, err)
+			}
+			cluster.addCleanup(func() { os.RemoveAll(path) })
+
+			store, err := raftboltdb.NewBoltStore(filepath.Join(path, "raft.db"))
+			if err != nil {
+				cluster.t.Fatalf("failed to create bolt store for node %d: %v", i, err)
+			}
+			node.Logs = store
+			node.Stable = store
+
+			snapshots, err := raft.NewFileSnapshotStore(path, 2, ioutil.Discard)
+			if err != nil {
+				cluster.t.Fatalf("failed to create snapshot store for node %d: %v", i, err)
+			}
+			node.Snapshots = snapshots
+		}
+	}}
+}
+
+// configKnob is a Knob that only needs to tweak the dependencies of a
+// node before the corresponding raft.Raft instance is created.
+type configKnob struct {
+	apply func(*cluster)
+}
+
+func (k *configKnob) pre(cluster *cluster) {
+	k.apply(cluster)
+}
+
+func (k *configKnob) post(rafts []*raft.Raft) {
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Canonical Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rafttest_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rafttest "github.com/CanonicalLtd/raft-test"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+// FileStores backs every node with on-disk log, stable and snapshot
+// stores, and removes them again when the cluster is shut down.
+func TestFileStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raft-test-store-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fsms := []raft.FSM{&recordingFSM{}, &recordingFSM{}}
+
+	notify := rafttest.Notify()
+	rafts, cleanup := rafttest.Cluster(t, fsms, notify, rafttest.FileStores(dir))
+
+	leader := notify.NextAcquired(time.Second)
+	future := rafts[leader].Apply([]byte("hello"), time.Second)
+	assert.NoError(t, future.Error())
+
+	entries, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, entry := range entries {
+		_, err := os.Stat(filepath.Join(dir, entry.Name(), "raft.db"))
+		assert.NoError(t, err)
+	}
+
+	cleanup()
+
+	entries, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+}